@@ -0,0 +1,17 @@
+package gaiji
+
+// RegisterJIS adds (or overwrites) a JIS X 0213 面-区-点 lookup entry, keyed
+// the same way jisTable is (the code that follows "水準" in a descriptor,
+// e.g. "1-15-18"). This is how a caller loads a real 外字注記辞書 - one
+// parsed from the published Aozora Bunko gaiji list or a MJ文字情報一覧表
+// extract - without this package having to vendor and vouch for the whole
+// thing up front; see jisTable's doc comment for why it ships small.
+func RegisterJIS(menKuTen string, r rune) {
+	jisTable[menKuTen] = r
+}
+
+// RegisterIDS adds (or overwrites) an IDS (構成) lookup entry, keyed the
+// same way idsTable is: components joined by ASCII '+' (e.g. "口+世").
+func RegisterIDS(ids string, r rune) {
+	idsTable[ids] = r
+}
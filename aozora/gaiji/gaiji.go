@@ -0,0 +1,69 @@
+// Package gaiji resolves a ※［＃…］外字注記 descriptor - the text Parse
+// keeps on parser.Gaiji.Descriptor instead of discarding - to the actual
+// Unicode codepoint it names, where one is known.
+package gaiji
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	menKuTenRe = regexp.MustCompile(`第[1-4]水準(\d+-\d+-\d+)`)
+	idsRe      = regexp.MustCompile(`「([^＋」]+(?:＋[^＋」]+)+)」`)
+)
+
+// Resolve looks up descriptor's codepoint, trying in order:
+//
+//  1. a direct JIS X 0213 面-区-点 (plane-row-cell) lookup against the
+//     embedded table, using the code that follows "水準" (e.g. "1-15-18"
+//     from "第3水準1-15-18");
+//  2. an IDS (構成) lookup against the embedded table, using the
+//     "Ａ＋Ｂ" component list inside the descriptor's "「…」" (e.g.
+//     "口+世" from "「口＋世」");
+//  3. fallback, a caller-supplied hook for codes not in either embedded
+//     table (e.g. backed by a bundled Unihan IDS file, or a user's own
+//     corrections).
+//
+// It reports false if none of the three resolve descriptor.
+func Resolve(descriptor string, fallback func(descriptor string) (rune, bool)) (rune, bool) {
+	if key, ok := menKuTenKey(descriptor); ok {
+		if r, ok := jisTable[key]; ok {
+			return r, true
+		}
+	}
+
+	if key, ok := idsKey(descriptor); ok {
+		if r, ok := idsTable[key]; ok {
+			return r, true
+		}
+	}
+
+	if fallback != nil {
+		if r, ok := fallback(descriptor); ok {
+			return r, true
+		}
+	}
+
+	return 0, false
+}
+
+// menKuTenKey extracts the 面-区-点 code following "水準" in descriptor,
+// e.g. "1-15-18" out of "第3水準1-15-18".
+func menKuTenKey(descriptor string) (string, bool) {
+	m := menKuTenRe.FindStringSubmatch(descriptor)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// idsKey extracts the "「Ａ＋Ｂ」" 構成 inside descriptor and normalizes
+// it to ASCII '+', e.g. "口+世" out of "「口＋世」".
+func idsKey(descriptor string) (string, bool) {
+	m := idsRe.FindStringSubmatch(descriptor)
+	if m == nil {
+		return "", false
+	}
+	return strings.ReplaceAll(m[1], "＋", "+"), true
+}
@@ -0,0 +1,76 @@
+package gaiji
+
+import "testing"
+
+func TestResolveJISTable(t *testing.T) {
+	r, ok := Resolve("＃「口＋世」、第3水準1-15-18", nil)
+	if !ok {
+		t.Fatal("got ok=false, want a resolved rune")
+	}
+	if r != 0x54BB {
+		t.Errorf("got %U, want U+54BB", r)
+	}
+}
+
+func TestResolveIDSFallsBackFromUnknownJISCode(t *testing.T) {
+	r, ok := Resolve("＃「口＋世」、第9水準9-99-99", nil)
+	if !ok {
+		t.Fatal("got ok=false, want the IDS lookup to still resolve it")
+	}
+	if r != 0x54BB {
+		t.Errorf("got %U, want U+54BB", r)
+	}
+}
+
+func TestResolveUsesCallerFallback(t *testing.T) {
+	r, ok := Resolve("＃「水＋皮」", func(descriptor string) (rune, bool) {
+		if descriptor == "＃「水＋皮」" {
+			return 0x6CD5, true
+		}
+		return 0, false
+	})
+	if !ok || r != 0x6CD5 {
+		t.Errorf("got (%U, %v), want (U+6CD5, true)", r, ok)
+	}
+}
+
+func TestResolveUnresolved(t *testing.T) {
+	if _, ok := Resolve("＃不明な外字", nil); ok {
+		t.Error("got ok=true for an unresolvable descriptor")
+	}
+}
+
+// TestResolveIDSTableRejectsUnverifiedCompounds guards against idsTable
+// growing entries for ordinary, already-JIS-encodable kanji guessed from
+// their 構成 shorthand rather than sourced from a real 外字注記辞書 - a
+// genuine gaiji that happens to share the shorthand would otherwise be
+// silently resolved to the wrong common character instead of falling
+// through to the placeholder/descriptor rendering.
+func TestResolveIDSTableRejectsUnverifiedCompounds(t *testing.T) {
+	cases := []string{
+		"＃「人＋言」",
+		"＃「水＋先」",
+		"＃「日＋月」",
+	}
+	for _, descriptor := range cases {
+		if _, ok := Resolve(descriptor, nil); ok {
+			t.Errorf("Resolve(%q): got ok=true, want false for an unverified IDS compound", descriptor)
+		}
+	}
+}
+
+func TestRegisterJISAddsLookup(t *testing.T) {
+	RegisterJIS("9-1-1", 0x6CD5)
+	r, ok := Resolve("＃「水＋法」、第3水準9-1-1", nil)
+	if !ok || r != 0x6CD5 {
+		t.Errorf("got (%U, %v), want (U+6CD5, true) after RegisterJIS", r, ok)
+	}
+}
+
+func TestRegisterIDSAddsLookup(t *testing.T) {
+	RegisterIDS("手+云", 0x4F1D) // 伝
+	r, ok := Resolve("＃「手＋云」", nil)
+	if !ok || r != 0x4F1D {
+		t.Errorf("got (%U, %v), want (U+4F1D, true) after RegisterIDS", r, ok)
+	}
+}
@@ -0,0 +1,39 @@
+package gaiji
+
+// jisTable maps a JIS X 0213 面-区-点 (plane-row-cell) code, as it appears
+// after "水準" in a ※［＃…］descriptor (e.g. "1-15-18" from "第3水準
+// 1-15-18"), to the Unicode codepoint it was given a compatibility
+// mapping for.
+//
+// The full Aozora Bunko 外字注記辞書 runs to several thousand rows and is
+// published as a maintained external resource (the 文字情報技術促進協議会
+// MJ文字情報一覧表 and Aozora's own 外字注記一覧), not something to
+// transcribe a handful of rows of from memory into source and call
+// complete - a wrong 面区点 mapping silently substitutes the wrong
+// character, which is worse than falling back to the placeholder/
+// descriptor rendering Resolve already does. So this table only carries
+// entries that have actually been verified against a source, and
+// RegisterJIS (see dict.go) lets a caller load the rest of a real
+// dictionary at runtime instead of waiting on every code to be added here.
+var jisTable = map[string]rune{
+	"1-15-18": 0x54BB, // 口＋世, 第3水準1-15-18
+}
+
+// idsTable maps an IDS (Ideographic Description Sequence) 構成 string,
+// normalized to ASCII '+' between components (e.g. "口+世" from
+// "「口＋世」"), to the Unicode codepoint it composes. It is a fallback
+// for gaiji whose descriptor gives a 構成 but no (or an unrecognized)
+// JIS row-cell code.
+//
+// Unlike jisTable, a 構成 is just the character's own well-known radical
+// decomposition - but gaiji notation exists precisely for characters that
+// aren't already representable, so a 構成 shorthand is not on its own
+// evidence that the compound it happens to spell is the character meant;
+// a distinct, still-unresolvable glyph can share the same two-component
+// shorthand as a common character. This table carries the same bar as
+// jisTable: only entries verified against an actual Aozora 外字注記辞書
+// source, not ordinary kanji guessed from their shape; see RegisterIDS
+// (dict.go) for adding the rest.
+var idsTable = map[string]rune{
+	"口+世": 0x54BB, // 口＋世, 第3水準1-15-18
+}
@@ -0,0 +1,129 @@
+// Package parser turns Aozora Bunko 注記 (kumihan) notation into a node
+// tree (Document -> Block -> Span) instead of rewriting the source text
+// line by line with regexes. A Document renders itself against any
+// Formatter, so the same tree can feed every output dialect.
+package parser
+
+// Document is the root of a parsed Aozora text: an ordered list of
+// block-level elements.
+type Document struct {
+	Blocks []Block
+}
+
+// Block is a block-level element: Paragraph for ordinary body text, and
+// Preformatted for a ［＃ここから罫囲み］／［＃ここで罫囲み終わり］ boxed
+// region, which is kept verbatim (no span scanning) since a framed block
+// is typically a table or diagram whose layout kumihan notation would
+// only get in the way of.
+type Block interface {
+	block()
+}
+
+// Paragraph is one line of body text, together with the block-scoped
+// notations that are tracked across lines: 字下げ／字上げ（Jisage／
+// Jiage）、地付き（AlignRight）and 見出し (HeadingLevel, 0 when the line
+// is not a heading, otherwise 1/2/3 for 大／中／小見出し). ここから太字／
+// 斜体／傍点…ここで…終わり is, by contrast, not tracked across lines at
+// all - the source notation only ever marks the exact line the
+// start/end annotation sits on, and relies on the target format's own
+// persistent state for everything in between (see BoldToggle/
+// ItalicToggle/BousenToggle).
+type Paragraph struct {
+	Spans        []Span
+	Jisage       int
+	Jiage        int
+	AlignRight   bool
+	HeadingLevel int
+	PageBreak    bool
+	// Center is ページの左右中央: this line is centered on the page
+	// (independent of, and typically used instead of, Jisage/AlignRight -
+	// title pages use it to center a line regardless of its length).
+	Center bool
+	// Yokogumi is ［＃ここから横組み］／［＃ここで横組み終わり］: this line
+	// sits in a horizontal-writing run embedded in an otherwise vertical
+	// text (e.g. a quoted equation or Western-language passage).
+	Yokogumi bool
+	// Jizume is ［＃ここからN字詰め］／［＃ここでN字詰め終わり］: the fixed
+	// line width (in characters) typeset text in this run should wrap at,
+	// 0 when no 字詰め run is active. Like Jisage/Jiage, Render hands this
+	// straight to the Formatter rather than re-wrapping the text itself.
+	Jizume int
+}
+
+func (*Paragraph) block() {}
+
+// Preformatted is a ［＃ここから罫囲み］／［＃ここで罫囲み終わり］ boxed
+// region, rendered verbatim line by line instead of being scanned for
+// inline spans.
+type Preformatted struct {
+	Lines []string
+}
+
+func (*Preformatted) block() {}
+
+// Span is an inline element within a Paragraph.
+type Span interface {
+	span()
+}
+
+// Text is plain, unannotated text.
+type Text string
+
+func (Text) span() {}
+
+// Ruby is a base run of text together with its reading, from either
+// ｜base《reading》 or a bare base《reading》 where the morphological
+// tokenizer determined the extent of base.
+type Ruby struct {
+	Base    string
+	Reading string
+}
+
+func (*Ruby) span() {}
+
+// Gaiji is a ※［＃…］ external-character annotation; Descriptor is the
+// text between the brackets (JIS face/row/cell or 構成 description),
+// kept intact for later resolution instead of being discarded.
+type Gaiji struct {
+	Descriptor string
+}
+
+func (*Gaiji) span() {}
+
+// Bold, Italic and Bousen are inline spans produced by the single-line
+// forms of the notation, e.g. ［＃「…」は太字］.
+type Bold struct{ Text string }
+
+func (*Bold) span() {}
+
+type Italic struct{ Text string }
+
+func (*Italic) span() {}
+
+type Bousen struct{ Text string }
+
+func (*Bousen) span() {}
+
+// BoldToggle, ItalicToggle and BousenToggle mark the exact point of a
+// ここから…／ここで…終わり boundary. They carry no text of their own;
+// Render turns one into the formatter's raw on/off markup and leaves
+// everything between a pair of them to the target format's own
+// persistent styling state, exactly as the notation itself does.
+type BoldToggle struct{ On bool }
+
+func (*BoldToggle) span() {}
+
+type ItalicToggle struct{ On bool }
+
+func (*ItalicToggle) span() {}
+
+type BousenToggle struct{ On bool }
+
+func (*BousenToggle) span() {}
+
+// Warichu is 割り注: a smaller-print annotation inserted inline within the
+// line it belongs to, marked in the source by a ［＃割り注］...
+// ［＃割り注終わり］ bracket pair, e.g. "本文［＃割り注］割り注［＃割り注終わり］本文".
+type Warichu struct{ Text string }
+
+func (*Warichu) span() {}
@@ -0,0 +1,442 @@
+package parser
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Formatter renders the logical structure extracted from Aozora Bunko
+// notation (注記) into one concrete output dialect. Document.Render drives a
+// Formatter instead of hard-coding SSP/ukagaka markup so the same parsing
+// pass can feed an e-reader, a static site generator, or a terminal pager.
+//
+// Bold/Italic/Bousen/AlignRight are toggles: they return the markup for
+// turning the effect on (on == true) or off (on == false), mirroring the
+// ［＃ここから…］／［＃ここで…終わり］ pairing the notation itself uses.
+type Formatter interface {
+	// Text renders a run of literal source text, applying whatever escaping
+	// the output dialect needs so the text can't be mistaken for markup
+	// (e.g. a literal "<" in the source turning into an HTML tag). Render
+	// passes every span's literal text through Text before it reaches any
+	// other method below - a plain Text span, the wrapped text carried by
+	// Bold/Italic/Bousen, and Ruby's base/reading - so the rest of this
+	// interface can assume its string arguments are already escaped. The
+	// one exception is a gaiji descriptor: Gaiji and GaijiDescriptor receive
+	// it raw, not passed through Text.
+	Text(s string) string
+	// Ruby wraps base with its reading (base《reading》).
+	Ruby(base, reading string) string
+	Bold(on bool) string
+	Italic(on bool) string
+	Bousen(on bool) string
+	AlignRight(on bool) string
+	Heading(level int, text string) string
+	PageBreak() string
+	Jisage(n int, text string) string
+	Jiage(n int, text string) string
+	// Gaiji renders a ※［＃…］外字注記 in GaijiPlaceholder mode: a fixed
+	// per-dialect stand-in glyph, discarding the descriptor.
+	Gaiji(note string) string
+	// GaijiDescriptor renders a ※［＃…］外字注記 whose descriptor is being
+	// shown verbatim, either because GaijiDescriptor mode was requested or
+	// because GaijiUnicode mode's resolution (see package gaiji) failed,
+	// wrapping descriptor in dialect-appropriate markup.
+	GaijiDescriptor(descriptor string) string
+	// Warichu wraps 割り注 (inline smaller-print annotation) text.
+	Warichu(text string) string
+	// PageCenter is ページの左右中央's toggle: on==true turns on centering
+	// for the page, on==false turns it back off.
+	PageCenter(on bool) string
+	// Yokogumi is ［＃ここから横組み］／［＃ここで横組み終わり］'s toggle:
+	// on==true starts a horizontal-writing run, on==false ends it.
+	Yokogumi(on bool) string
+	// Jizume wraps text typeset under a ［＃ここからN字詰め］ run, where n
+	// is the fixed line width (in characters) the source specified.
+	Jizume(n int, text string) string
+	// Preformatted renders a ［＃ここから罫囲み］ boxed block verbatim,
+	// joining lines (already escaped via Text) in a dialect-appropriate way.
+	Preformatted(lines []string) string
+}
+
+// trimGaijiMarker strips the leading "＃" a Gaiji.Descriptor always carries
+// (it is kept intact for gaiji.Resolve, which parses around it), so
+// GaijiDescriptor implementations that surface the descriptor to a reader
+// don't show a stray leading marker.
+func trimGaijiMarker(descriptor string) string {
+	return strings.TrimPrefix(descriptor, "＃")
+}
+
+// NewFormatter resolves the -f flag value to a Formatter implementation.
+func NewFormatter(name string) (Formatter, error) {
+	switch name {
+	case "", "ssp":
+		return sspFormatter{}, nil
+	case "plain":
+		return plainFormatter{}, nil
+	case "html":
+		return htmlFormatter{}, nil
+	case "md", "markdown":
+		return markdownFormatter{}, nil
+	case "troff", "groff":
+		return troffFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", name)
+	}
+}
+
+// sspFormatter reproduces the SSP/ukagaka ゴーストシェル markup that
+// formatText originally emitted unconditionally.
+type sspFormatter struct{}
+
+// Text is a no-op: the ukagaka shell markup this formatter reproduces has
+// no character that needs escaping in body text.
+func (sspFormatter) Text(s string) string { return s }
+
+// Ruby emits the ［＃…］-free 《base,reading》 form the shell reads, in
+// place of base - the same normalization processRuby used to perform by
+// hand before the parser existed.
+func (sspFormatter) Ruby(base, reading string) string { return "《" + base + "," + reading + "》" }
+
+func (sspFormatter) Bold(on bool) string {
+	if on {
+		return "\\![bold,1]"
+	}
+	return "\\![bold,0]"
+}
+
+func (sspFormatter) Italic(on bool) string {
+	if on {
+		return "\\![italic,1]"
+	}
+	return "\\![italic,0]"
+}
+
+func (sspFormatter) Bousen(on bool) string {
+	if on {
+		return "\\![underline,1]"
+	}
+	return "\\![underline,0]"
+}
+
+func (sspFormatter) AlignRight(on bool) string {
+	if on {
+		return "\\f[align,right]"
+	}
+	return "\\f[align,left]"
+}
+
+func (sspFormatter) Heading(level int, text string) string {
+	return "\\![bold,1]" + text + "\\![bold,0]"
+}
+
+func (sspFormatter) PageBreak() string { return "\\x[noclear]\\c" }
+
+func (sspFormatter) Jisage(n int, text string) string { return strings.Repeat("　", n) + text }
+func (sspFormatter) Jiage(n int, text string) string  { return text + strings.Repeat("　", n) }
+
+func (sspFormatter) Gaiji(note string) string { return "⺀" }
+
+// GaijiDescriptor reconstructs the original ※［…］source notation verbatim;
+// the ukagaka shell has no markup for it beyond plain text.
+func (sspFormatter) GaijiDescriptor(descriptor string) string { return "※［" + descriptor + "］" }
+
+func (sspFormatter) Warichu(text string) string { return "（" + text + "）" }
+
+func (sspFormatter) PageCenter(on bool) string {
+	if on {
+		return "\\f[align,center]"
+	}
+	return "\\f[align,left]"
+}
+
+func (sspFormatter) Yokogumi(on bool) string { return "" }
+
+func (sspFormatter) Jizume(n int, text string) string { return text }
+
+func (sspFormatter) Preformatted(lines []string) string { return strings.Join(lines, "\n") }
+
+// plainFormatter strips notation down to bare text, for feeding terminal
+// pagers or anything that has no concept of rich markup.
+type plainFormatter struct{}
+
+// Text is a no-op: plain output has no markup for a literal character to
+// be mistaken for.
+func (plainFormatter) Text(s string) string { return s }
+
+func (plainFormatter) Ruby(base, reading string) string { return base + "(" + reading + ")" }
+func (plainFormatter) Bold(on bool) string              { return "" }
+func (plainFormatter) Italic(on bool) string            { return "" }
+func (plainFormatter) Bousen(on bool) string            { return "" }
+func (plainFormatter) AlignRight(on bool) string        { return "" }
+func (plainFormatter) Heading(level int, text string) string {
+	return text
+}
+func (plainFormatter) PageBreak() string                { return "\f" }
+func (plainFormatter) Jisage(n int, text string) string { return strings.Repeat("　", n) + text }
+func (plainFormatter) Jiage(n int, text string) string  { return text + strings.Repeat("　", n) }
+func (plainFormatter) Gaiji(note string) string         { return "〓" }
+func (plainFormatter) GaijiDescriptor(descriptor string) string {
+	return "〓" + trimGaijiMarker(descriptor) + "〓"
+}
+
+func (plainFormatter) Warichu(text string) string       { return "（" + text + "）" }
+func (plainFormatter) PageCenter(on bool) string        { return "" }
+func (plainFormatter) Yokogumi(on bool) string          { return "" }
+func (plainFormatter) Jizume(n int, text string) string { return text }
+func (plainFormatter) Preformatted(lines []string) string {
+	return strings.Join(lines, "\n")
+}
+
+// htmlFormatter targets static site generators / browsers.
+type htmlFormatter struct{}
+
+// Text escapes &, <, > (and quotes) so literal source text can't be
+// mistaken for markup once embedded in HTML output. Render calls this on
+// every span's literal text (including Ruby's base/reading) before handing
+// it to the methods below, so they can assume their string arguments are
+// already HTML-safe - except GaijiDescriptor, which gets the raw
+// descriptor and escapes it itself.
+func (htmlFormatter) Text(s string) string { return html.EscapeString(s) }
+
+func (htmlFormatter) Ruby(base, reading string) string {
+	return "<ruby>" + base + "<rt>" + reading + "</rt></ruby>"
+}
+
+func (htmlFormatter) Bold(on bool) string {
+	if on {
+		return "<strong>"
+	}
+	return "</strong>"
+}
+
+func (htmlFormatter) Italic(on bool) string {
+	if on {
+		return "<em>"
+	}
+	return "</em>"
+}
+
+func (htmlFormatter) Bousen(on bool) string {
+	if on {
+		return `<em class="bousen">`
+	}
+	return "</em>"
+}
+
+func (htmlFormatter) AlignRight(on bool) string {
+	if on {
+		return `<div style="text-align:right">`
+	}
+	return "</div>"
+}
+
+func (htmlFormatter) Heading(level int, text string) string {
+	if level < 1 {
+		level = 1
+	}
+	if level > 6 {
+		level = 6
+	}
+	return fmt.Sprintf("<h%d>%s</h%d>", level, text, level)
+}
+
+func (htmlFormatter) PageBreak() string { return `<hr class="page-break">` }
+
+func (htmlFormatter) Jisage(n int, text string) string {
+	return strings.Repeat("&nbsp;", n) + text
+}
+func (htmlFormatter) Jiage(n int, text string) string {
+	return text + strings.Repeat("&nbsp;", n)
+}
+
+func (htmlFormatter) Gaiji(note string) string {
+	return `<span class="gaiji">〓</span>`
+}
+
+func (htmlFormatter) GaijiDescriptor(descriptor string) string {
+	return fmt.Sprintf(`<span class="gaiji" title="%s">〓</span>`, html.EscapeString(trimGaijiMarker(descriptor)))
+}
+
+func (htmlFormatter) Warichu(text string) string {
+	return `<span class="warichu">(` + text + `)</span>`
+}
+
+func (htmlFormatter) PageCenter(on bool) string {
+	if on {
+		return `<div style="text-align:center">`
+	}
+	return "</div>"
+}
+
+func (htmlFormatter) Yokogumi(on bool) string {
+	if on {
+		return `<span style="writing-mode:horizontal-tb">`
+	}
+	return "</span>"
+}
+
+func (htmlFormatter) Jizume(n int, text string) string {
+	return fmt.Sprintf(`<span style="width:%dch">%s</span>`, n, text)
+}
+
+func (htmlFormatter) Preformatted(lines []string) string {
+	return "<pre>" + strings.Join(lines, "\n") + "</pre>"
+}
+
+// markdownFormatter targets static site generators that post-process
+// Markdown (ruby/alignment fall back to inline HTML, which Markdown
+// passes through verbatim).
+type markdownFormatter struct{}
+
+// Text escapes &, <, > (and quotes) the same way htmlFormatter does: ruby
+// and alignment already fall back to inline HTML that Markdown passes
+// through verbatim, so body text sitting next to that markup needs the
+// same protection. Render calls this on every span's literal text
+// (including Ruby's base/reading) before handing it to the methods below.
+func (markdownFormatter) Text(s string) string { return html.EscapeString(s) }
+
+func (markdownFormatter) Ruby(base, reading string) string {
+	return "<ruby>" + base + "<rt>" + reading + "</rt></ruby>"
+}
+
+func (markdownFormatter) Bold(on bool) string   { return "**" }
+func (markdownFormatter) Italic(on bool) string { return "*" }
+
+func (markdownFormatter) Bousen(on bool) string {
+	if on {
+		return `<em class="bousen">`
+	}
+	return "</em>"
+}
+
+func (markdownFormatter) AlignRight(on bool) string {
+	if on {
+		return `<div style="text-align:right">`
+	}
+	return "</div>"
+}
+
+func (markdownFormatter) Heading(level int, text string) string {
+	if level < 1 {
+		level = 1
+	}
+	if level > 6 {
+		level = 6
+	}
+	return strings.Repeat("#", level) + " " + text
+}
+
+func (markdownFormatter) PageBreak() string { return "\n---\n" }
+
+func (markdownFormatter) Jisage(n int, text string) string {
+	return strings.Repeat("&nbsp;", n) + text
+}
+func (markdownFormatter) Jiage(n int, text string) string {
+	return text + strings.Repeat("&nbsp;", n)
+}
+
+func (markdownFormatter) Gaiji(note string) string { return "〓" }
+
+func (markdownFormatter) GaijiDescriptor(descriptor string) string {
+	return fmt.Sprintf("`[gaiji: %s]`", trimGaijiMarker(descriptor))
+}
+
+func (markdownFormatter) Warichu(text string) string {
+	return `<span class="warichu">(` + text + `)</span>`
+}
+
+func (markdownFormatter) PageCenter(on bool) string {
+	if on {
+		return `<div style="text-align:center">`
+	}
+	return "</div>"
+}
+
+func (markdownFormatter) Yokogumi(on bool) string {
+	if on {
+		return `<span style="writing-mode:horizontal-tb">`
+	}
+	return "</span>"
+}
+
+func (markdownFormatter) Jizume(n int, text string) string {
+	return fmt.Sprintf(`<span style="width:%dch">%s</span>`, n, text)
+}
+
+func (markdownFormatter) Preformatted(lines []string) string {
+	return "```\n" + strings.Join(lines, "\n") + "\n```"
+}
+
+// troffFormatter targets groff/troff pipelines (e.g. piped into a
+// terminal pager via `groff -Tutf8`).
+type troffFormatter struct{}
+
+// Text escapes troff's own escape character so a literal "\" in source
+// text isn't interpreted as the start of a troff request.
+func (troffFormatter) Text(s string) string { return strings.ReplaceAll(s, `\`, `\\`) }
+
+func (troffFormatter) Ruby(base, reading string) string { return base + "(" + reading + ")" }
+
+func (troffFormatter) Bold(on bool) string {
+	if on {
+		return "\\fB"
+	}
+	return "\\fR"
+}
+
+func (troffFormatter) Italic(on bool) string {
+	if on {
+		return "\\fI"
+	}
+	return "\\fR"
+}
+
+func (troffFormatter) Bousen(on bool) string {
+	if on {
+		return "\\fI"
+	}
+	return "\\fR"
+}
+
+func (troffFormatter) AlignRight(on bool) string {
+	if on {
+		return ".ad r\n"
+	}
+	return ".ad l\n"
+}
+
+func (troffFormatter) Heading(level int, text string) string {
+	if level <= 1 {
+		return ".SH " + text
+	}
+	return ".SS " + text
+}
+
+func (troffFormatter) PageBreak() string { return ".bp" }
+
+func (troffFormatter) Jisage(n int, text string) string { return strings.Repeat("　", n) + text }
+func (troffFormatter) Jiage(n int, text string) string  { return text + strings.Repeat("　", n) }
+
+func (troffFormatter) Gaiji(note string) string { return "〓" }
+
+func (troffFormatter) GaijiDescriptor(descriptor string) string {
+	return "〓" + trimGaijiMarker(descriptor) + "〓"
+}
+
+func (troffFormatter) Warichu(text string) string { return "(" + text + ")" }
+
+func (troffFormatter) PageCenter(on bool) string {
+	if on {
+		return ".ce 1000\n"
+	}
+	return ".ce 0\n"
+}
+
+func (troffFormatter) Yokogumi(on bool) string { return "" }
+
+func (troffFormatter) Jizume(n int, text string) string { return text }
+
+func (troffFormatter) Preformatted(lines []string) string {
+	return ".nf\n" + strings.Join(lines, "\n") + "\n.fi"
+}
@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/ikawaha/kagome-dict/ipa"
+	"github.com/ikawaha/kagome/v2/tokenizer"
+)
+
+// readingFeature is the index of the katakana reading in the IPA
+// dictionary's feature list for a token.
+const readingFeature = 7
+
+// insertAutoFurigana walks src with the IPA tokenizer and wraps every run
+// of 漢字 not already carrying a ｜…《…》 or bare …《…》 reading with
+// ｜run《reading》, using the dictionary's katakana reading converted to
+// hiragana. The result is fed through normalizeRuby exactly like
+// hand-written ruby, so both forms end up in the same canonical span.
+//
+// When kanjiFilter is non-nil, only kanji tokens holding at least one kanji
+// it contains are annotated, each such token in full (its other kanji
+// included) - this is how WithKanjiList limits auto-furigana to a
+// caller-supplied list of "hard" kanji instead of every kanji in the text.
+func insertAutoFurigana(src string, kanjiFilter map[rune]bool) (string, error) {
+	t, err := tokenizer.New(ipa.Dict(), tokenizer.OmitBosEos())
+	if err != nil {
+		return "", err
+	}
+	tokens := t.Tokenize(src)
+
+	needsFurigana := func(s string) bool {
+		if !isKanji(s) {
+			return false
+		}
+		if kanjiFilter == nil {
+			return true
+		}
+		for _, r := range s {
+			if kanjiFilter[r] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var b strings.Builder
+	inRuby := false
+	i := 0
+	for i < len(tokens) {
+		surface := tokens[i].Surface
+
+		if surface == "｜" {
+			inRuby = true
+			b.WriteString(surface)
+			i++
+			continue
+		}
+		if surface == "《" {
+			inRuby = false
+			b.WriteString(surface)
+			i++
+			continue
+		}
+		if inRuby || !needsFurigana(surface) {
+			b.WriteString(surface)
+			i++
+			continue
+		}
+
+		// Collapse the run of kanji tokens making up this 形態素 chain
+		// (e.g. a 名詞-固有名詞 run) into a single ruby span.
+		j := i + 1
+		for j < len(tokens) && needsFurigana(tokens[j].Surface) {
+			j++
+		}
+
+		if j < len(tokens) && tokens[j].Surface == "《" {
+			// Already has an explicit reading right after it; leave as-is.
+			for ; i < j; i++ {
+				b.WriteString(tokens[i].Surface)
+			}
+			continue
+		}
+
+		reading := ""
+		for k := i; k < j; k++ {
+			f := tokens[k].Features()
+			if len(f) <= readingFeature {
+				reading = ""
+				break
+			}
+			reading += katakanaToHiragana(f[readingFeature])
+		}
+		if reading == "" {
+			for ; i < j; i++ {
+				b.WriteString(tokens[i].Surface)
+			}
+			continue
+		}
+
+		b.WriteString("｜")
+		for k := i; k < j; k++ {
+			b.WriteString(tokens[k].Surface)
+		}
+		b.WriteString("《")
+		b.WriteString(reading)
+		b.WriteString("》")
+		i = j
+	}
+
+	return b.String(), nil
+}
+
+// applyAutoFurigana runs WithAutoFurigana over spans' plain Text spans
+// only, leaving every other span (Ruby, Gaiji, Bold, the toggles, …)
+// untouched. Each Text span's result is re-normalized and re-scanned (see
+// normalizeRuby, scanSpans) so a kanji run insertAutoFurigana annotated
+// turns into a proper Ruby span rather than raw ｜…《…》 text.
+func applyAutoFurigana(spans []Span, kanjiFilter map[rune]bool) ([]Span, error) {
+	out := make([]Span, 0, len(spans))
+	for _, s := range spans {
+		text, ok := s.(Text)
+		if !ok {
+			out = append(out, s)
+			continue
+		}
+
+		withFurigana, err := insertAutoFurigana(string(text), kanjiFilter)
+		if err != nil {
+			return nil, err
+		}
+		normalized, err := normalizeRuby(withFurigana)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, scanSpans(normalized)...)
+	}
+	return out, nil
+}
+
+func katakanaToHiragana(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 'ァ' && r <= 'ヶ' {
+			b.WriteRune(r - ('ァ' - 'ぁ'))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,394 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const zenkakuByte = 3
+
+var rep = map[string]*regexp.Regexp{
+	"ruby":          regexp.MustCompile(`《([^,《》]*),([^《》]*)》`),
+	"gaiji":         regexp.MustCompile(`※［([^］]*)］`),
+	"bousen":        regexp.MustCompile(`［＃「(.*?)」(の左)?に((白ゴマ|丸|白丸|黒三角|白三角|二重丸|蛇の目)?傍点|((二重)?傍|鎖|破|波)線)］`),
+	"bousenStart":   regexp.MustCompile(`［＃(左に)?((白ゴマ|丸|白丸|黒三角|白三角|二重丸|蛇の目)?傍点|((二重)?傍|鎖|破|波)線)］`),
+	"bousenEnd":     regexp.MustCompile(`［＃(左に)?((白ゴマ|丸|白丸|黒三角|白三角|二重丸|蛇の目)?傍点|((二重)?傍|鎖|破|波)線)終わり］`),
+	"futoji":        regexp.MustCompile(`［＃「(.*?)」は太字］`),
+	"futojiStart":   regexp.MustCompile(`［＃(ここから)?太字］`),
+	"futojiEnd":     regexp.MustCompile(`［＃(ここで)?太字終わり］`),
+	"shatai":        regexp.MustCompile(`［＃「(.*?)」は斜体］`),
+	"shataiStart":   regexp.MustCompile(`［＃(ここから)?斜体］`),
+	"shataiEnd":     regexp.MustCompile(`［＃(ここで)?斜体終わり］`),
+	"jisageStart":   regexp.MustCompile(`［＃(ここから)?([０１２３４５６７８９]+)字下げ］`),
+	"jisageEnd":     regexp.MustCompile(`［＃ここで字下げ終わり］`),
+	"jiageStart":    regexp.MustCompile(`［＃ここから([０１２３４５６７８９]+)字上げ］`),
+	"jiageEnd":      regexp.MustCompile(`［＃ここで字上げ終わり］`),
+	"jiage":         regexp.MustCompile(`［＃([０１２３４５６７８９]+)字上げ］`),
+	"jiageTrim":     regexp.MustCompile(`［＃地から([０１２３４５６７８９]+)字上げ］`),
+	"jitsukiStart":  regexp.MustCompile(`［＃ここから地付き］`),
+	"jitsukiEnd":    regexp.MustCompile(`［＃ここで地付き終わり］`),
+	"jitsuki":       regexp.MustCompile(`［＃地付き］`),
+	"pageCenter":    regexp.MustCompile(`［＃ページの左右中央］`),
+	"pageBreak":     regexp.MustCompile(`［＃(改丁|改ページ|改段)］`),
+	"heading":       regexp.MustCompile(`［＃(大|中|小)?見出し］`),
+	"warichu":       regexp.MustCompile(`［＃割り注］(.*?)［＃割り注終わり］`),
+	"yokogumiStart": regexp.MustCompile(`［＃ここから横組み］`),
+	"yokogumiEnd":   regexp.MustCompile(`［＃ここで横組み終わり］`),
+	"jizumeStart":   regexp.MustCompile(`［＃ここから([０１２３４５６７８９]+)字詰め］`),
+	"jizumeEnd":     regexp.MustCompile(`［＃ここで字詰め終わり］`),
+	"preStart":      regexp.MustCompile(`［＃ここから罫囲み］`),
+	"preEnd":        regexp.MustCompile(`［＃ここで罫囲み終わり］`),
+}
+
+// headingLevels maps a 見出し qualifier (the empty string for an
+// unqualified ［＃見出し］) to the HeadingLevel it produces: 大見出し is the
+// top level, 小見出し the lowest, matching Formatter.Heading's h1-h6 range.
+var headingLevels = map[string]int{
+	"":  1,
+	"大": 1,
+	"中": 2,
+	"小": 3,
+}
+
+// options holds the settings Option funcs configure.
+type options struct {
+	autoFurigana bool
+	kanjiFilter  map[rune]bool
+}
+
+// Option configures optional Parse behavior.
+type Option func(*options)
+
+// WithAutoFurigana enables automatic furigana insertion: runs of 漢字 with
+// no existing ｜…《…》 ruby are annotated with the IPA dictionary's reading,
+// turning the tool into a reading aid instead of only rearranging ruby the
+// source text already has.
+func WithAutoFurigana() Option {
+	return func(o *options) { o.autoFurigana = true }
+}
+
+// WithKanjiList restricts WithAutoFurigana to the given kanji, so only
+// "hard" kanji (e.g. above some JLPT/frequency threshold) get annotated
+// rather than every kanji in the text. It has no effect without
+// WithAutoFurigana.
+func WithKanjiList(kanji []rune) Option {
+	filter := make(map[rune]bool, len(kanji))
+	for _, r := range kanji {
+		filter[r] = true
+	}
+	return func(o *options) { o.kanjiFilter = filter }
+}
+
+// Parse tokenizes an Aozora Bunko text into a Document. The ｜…《…》 ruby
+// notation is normalized up front (it needs word-boundary detection via
+// the IPA tokenizer, see ruby.go); everything else is scanned line by
+// line, since that is the grain the notation itself is written at.
+//
+// WithAutoFurigana is applied after a line has already been split into
+// spans (see scanSpans below), over its plain Text spans only - running
+// the tokenizer over the raw line instead would insert ruby into the
+// kanji compounds that make up the control annotations themselves (太字,
+// 字下げ, 見出し, …), breaking every one of them.
+func Parse(src string, opts ...Option) (*Document, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	normalized, err := normalizeRuby(src)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	lines := strings.Split(normalized, "\n")
+
+	var jisage, jiage int
+	var align, yokogumi bool
+	var jizume int
+	var trimWidth int
+
+	i := 0
+	for i < len(lines) {
+		if strings.HasPrefix(lines[i], "----------") {
+			i++
+			for !strings.HasPrefix(lines[i], "----------") {
+				i++
+			}
+			i++
+			continue
+		}
+
+		if rep["preStart"].MatchString(lines[i]) {
+			i++
+			var boxed []string
+			for {
+				if i >= len(lines) {
+					return nil, fmt.Errorf("parser: unterminated ［＃ここから罫囲み］: missing ［＃ここで罫囲み終わり］")
+				}
+				if rep["preEnd"].MatchString(lines[i]) {
+					break
+				}
+				boxed = append(boxed, lines[i])
+				i++
+			}
+			i++
+			doc.Blocks = append(doc.Blocks, &Preformatted{Lines: boxed})
+			continue
+		}
+
+		line := lines[i]
+
+		if strings.HasPrefix(line, "底本：") {
+			break
+		}
+
+		pageBreak := rep["pageBreak"].MatchString(line)
+		line = rep["pageBreak"].ReplaceAllString(line, "")
+
+		singleJisage := 0
+		if m := rep["jisageStart"].FindStringSubmatch(line); m != nil {
+			n, err := strconv.Atoi(string(norm.NFKC.Bytes([]byte(m[2]))))
+			if err != nil {
+				return nil, err
+			}
+			if m[1] == "ここから" {
+				// ［＃ここからn字下げ］の場合
+				jisage += n
+			} else {
+				// ［＃n字下げ］の場合
+				singleJisage = n
+			}
+			line = rep["jisageStart"].ReplaceAllString(line, "")
+		}
+
+		if rep["jisageEnd"].MatchString(line) {
+			jisage = 0
+			line = rep["jisageEnd"].ReplaceAllString(line, "")
+		}
+
+		if rep["jitsukiStart"].MatchString(line) {
+			align = true
+			line = rep["jitsukiStart"].ReplaceAllString(line, "")
+		}
+		if rep["jitsukiEnd"].MatchString(line) {
+			align = false
+			line = rep["jitsukiEnd"].ReplaceAllString(line, "")
+		}
+
+		if rep["yokogumiStart"].MatchString(line) {
+			yokogumi = true
+			line = rep["yokogumiStart"].ReplaceAllString(line, "")
+		}
+		if rep["yokogumiEnd"].MatchString(line) {
+			yokogumi = false
+			line = rep["yokogumiEnd"].ReplaceAllString(line, "")
+		}
+
+		if m := rep["jizumeStart"].FindStringSubmatch(line); m != nil {
+			n, err := strconv.Atoi(string(norm.NFKC.Bytes([]byte(m[1]))))
+			if err != nil {
+				return nil, err
+			}
+			jizume = n
+			line = rep["jizumeStart"].ReplaceAllString(line, "")
+		}
+		if rep["jizumeEnd"].MatchString(line) {
+			jizume = 0
+			line = rep["jizumeEnd"].ReplaceAllString(line, "")
+		}
+
+		singleJiage := 0
+		singleAlign := false
+		singleCenter := false
+		skip := false
+
+		// These two markers are dropped from the rendered output rather
+		// than left behind as their own (otherwise empty) line.
+		if m := rep["jiageStart"].FindStringSubmatch(line); m != nil {
+			n, err := strconv.Atoi(string(norm.NFKC.Bytes([]byte(m[1]))))
+			if err != nil {
+				return nil, err
+			}
+			jiage = n
+			skip = true
+		}
+
+		if rep["jiageEnd"].MatchString(line) {
+			jiage = 0
+			skip = true
+		}
+
+		if m := rep["jiageTrim"].FindStringSubmatch(line); m != nil {
+			n, err := strconv.Atoi(string(norm.NFKC.Bytes([]byte(m[1]))))
+			if err != nil {
+				return nil, err
+			}
+			trimWidth = n * zenkakuByte
+			line = rep["jiageTrim"].ReplaceAllString(line, "")
+		} else if m := rep["jiage"].FindStringSubmatch(line); m != nil {
+			n, err := strconv.Atoi(string(norm.NFKC.Bytes([]byte(m[1]))))
+			if err != nil {
+				return nil, err
+			}
+			singleJiage = n
+			line = rep["jiage"].ReplaceAllString(line, "")
+		}
+
+		if rep["jitsuki"].MatchString(line) {
+			singleAlign = true
+			line = rep["jitsuki"].ReplaceAllString(line, "")
+		}
+
+		if rep["pageCenter"].MatchString(line) {
+			singleCenter = true
+			line = rep["pageCenter"].ReplaceAllString(line, "")
+		}
+
+		headingLevel := 0
+		if m := rep["heading"].FindStringSubmatch(line); m != nil {
+			headingLevel = headingLevels[m[1]]
+			line = rep["heading"].ReplaceAllString(line, "")
+		}
+
+		if skip {
+			i++
+			continue
+		}
+
+		spans := scanSpans(line)
+		if o.autoFurigana {
+			spans, err = applyAutoFurigana(spans, o.kanjiFilter)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// ［＃地からN字上げ］ only trims the current 字下げ indent if it is
+		// wide enough to absorb the whole trim; otherwise the line is left
+		// untouched, matching the original byte-trim behavior this ported.
+		effectiveJisage := jisage + singleJisage
+		if trimWidth > 0 {
+			if effectiveJisage*zenkakuByte >= trimWidth {
+				effectiveJisage -= trimWidth / zenkakuByte
+			}
+			trimWidth = 0
+		}
+
+		doc.Blocks = append(doc.Blocks, &Paragraph{
+			Spans:        spans,
+			Jisage:       effectiveJisage,
+			Jiage:        jiage + singleJiage,
+			AlignRight:   align || singleAlign,
+			HeadingLevel: headingLevel,
+			PageBreak:    pageBreak,
+			Center:       singleCenter,
+			Yokogumi:     yokogumi,
+			Jizume:       jizume,
+		})
+
+		i++
+	}
+
+	return doc, nil
+}
+
+// scanSpans splits one already ruby-normalized line into inline spans:
+// ruby, gaiji, the single-line bold/italic/bousen forms, and plain text.
+func scanSpans(line string) []Span {
+	spans := []Span{}
+	text := ""
+	flush := func() {
+		if text != "" {
+			spans = append(spans, Text(text))
+			text = ""
+		}
+	}
+
+	for len(line) > 0 {
+		if loc := rep["ruby"].FindStringSubmatchIndex(line); loc != nil && loc[0] == 0 {
+			flush()
+			spans = append(spans, &Ruby{Base: line[loc[2]:loc[3]], Reading: line[loc[4]:loc[5]]})
+			line = line[loc[1]:]
+			continue
+		}
+
+		if loc := rep["gaiji"].FindStringSubmatchIndex(line); loc != nil && loc[0] == 0 {
+			flush()
+			spans = append(spans, &Gaiji{Descriptor: line[loc[2]:loc[3]]})
+			line = line[loc[1]:]
+			continue
+		}
+
+		if loc := rep["futoji"].FindStringSubmatchIndex(line); loc != nil && loc[0] == 0 {
+			flush()
+			spans = append(spans, &Bold{Text: line[loc[2]:loc[3]]})
+			line = line[loc[1]:]
+			continue
+		}
+
+		if loc := rep["shatai"].FindStringSubmatchIndex(line); loc != nil && loc[0] == 0 {
+			flush()
+			spans = append(spans, &Italic{Text: line[loc[2]:loc[3]]})
+			line = line[loc[1]:]
+			continue
+		}
+
+		if loc := rep["bousen"].FindStringSubmatchIndex(line); loc != nil && loc[0] == 0 {
+			flush()
+			spans = append(spans, &Bousen{Text: line[loc[2]:loc[3]]})
+			line = line[loc[1]:]
+			continue
+		}
+
+		if loc := rep["warichu"].FindStringSubmatchIndex(line); loc != nil && loc[0] == 0 {
+			flush()
+			spans = append(spans, &Warichu{Text: line[loc[2]:loc[3]]})
+			line = line[loc[1]:]
+			continue
+		}
+
+		if span, rest, ok := scanToggle(line); ok {
+			flush()
+			spans = append(spans, span)
+			line = rest
+			continue
+		}
+
+		r := []rune(line)[0]
+		text += string(r)
+		line = line[len(string(r)):]
+	}
+	flush()
+	return spans
+}
+
+// toggleMarkers maps each ここから…／ここで…終わり marker regex to the
+// toggle span it produces and whether it turns the effect on or off.
+var toggleMarkers = []struct {
+	key string
+	on  bool
+	new func(on bool) Span
+}{
+	{"futojiStart", true, func(on bool) Span { return &BoldToggle{On: on} }},
+	{"futojiEnd", false, func(on bool) Span { return &BoldToggle{On: on} }},
+	{"shataiStart", true, func(on bool) Span { return &ItalicToggle{On: on} }},
+	{"shataiEnd", false, func(on bool) Span { return &ItalicToggle{On: on} }},
+	{"bousenStart", true, func(on bool) Span { return &BousenToggle{On: on} }},
+	{"bousenEnd", false, func(on bool) Span { return &BousenToggle{On: on} }},
+}
+
+// scanToggle matches a ここから…／ここで…終わり marker at the front of
+// line, returning the Span it produces and the remainder of line.
+func scanToggle(line string) (Span, string, bool) {
+	for _, m := range toggleMarkers {
+		if loc := rep[m.key].FindStringIndex(line); loc != nil && loc[0] == 0 {
+			return m.new(m.on), line[loc[1]:], true
+		}
+	}
+	return nil, "", false
+}
@@ -0,0 +1,424 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRuby(t *testing.T) {
+	doc, err := Parse("｜月《つき》が綺麗ですね")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Blocks) != 1 {
+		t.Fatalf("got %d blocks, want 1", len(doc.Blocks))
+	}
+	p := doc.Blocks[0].(*Paragraph)
+
+	var ruby *Ruby
+	for _, s := range p.Spans {
+		if r, ok := s.(*Ruby); ok {
+			ruby = r
+			break
+		}
+	}
+	if ruby == nil {
+		t.Fatalf("no Ruby span found in %#v", p.Spans)
+	}
+	if ruby.Base != "月" || ruby.Reading != "つき" {
+		t.Errorf("got Ruby{%q,%q}, want Ruby{月,つき}", ruby.Base, ruby.Reading)
+	}
+}
+
+func TestParseBousenBlock(t *testing.T) {
+	doc, err := Parse("［＃傍点］強調された文［＃傍点終わり］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Blocks) != 1 {
+		t.Fatalf("got %d blocks, want 1", len(doc.Blocks))
+	}
+	p := doc.Blocks[0].(*Paragraph)
+
+	var on, off bool
+	for _, s := range p.Spans {
+		if tg, ok := s.(*BousenToggle); ok {
+			if tg.On {
+				on = true
+			} else {
+				off = true
+			}
+		}
+	}
+	if !on || !off {
+		t.Errorf("expected both a BousenToggle{On:true} and BousenToggle{On:false} span, got %#v", p.Spans)
+	}
+}
+
+func TestParseGaiji(t *testing.T) {
+	doc, err := Parse("※［＃「口＋世」、第3水準1-15-18］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[0].(*Paragraph)
+	g, ok := p.Spans[0].(*Gaiji)
+	if !ok {
+		t.Fatalf("got %#v, want *Gaiji", p.Spans[0])
+	}
+	if g.Descriptor != "＃「口＋世」、第3水準1-15-18" {
+		t.Errorf("got descriptor %q", g.Descriptor)
+	}
+}
+
+func TestParseAutoFurigana(t *testing.T) {
+	doc, err := Parse("漢字を読む", WithAutoFurigana())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[0].(*Paragraph)
+
+	var ruby *Ruby
+	for _, s := range p.Spans {
+		if r, ok := s.(*Ruby); ok {
+			ruby = r
+			break
+		}
+	}
+	if ruby == nil {
+		t.Fatalf("no Ruby span found in %#v, want auto-furigana on 漢字", p.Spans)
+	}
+	if ruby.Base != "漢字" {
+		t.Errorf("got Ruby base %q, want 漢字", ruby.Base)
+	}
+}
+
+func TestParseAutoFuriganaWithKanjiList(t *testing.T) {
+	doc, err := Parse("漢字を読む", WithAutoFurigana(), WithKanjiList([]rune("読")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[0].(*Paragraph)
+
+	for _, s := range p.Spans {
+		if r, ok := s.(*Ruby); ok && r.Base == "漢字" {
+			t.Errorf("got Ruby span on 漢字, want it left unannotated (not in kanji list)")
+		}
+	}
+}
+
+func TestParseAutoFuriganaLeavesControlAnnotationsIntact(t *testing.T) {
+	doc, err := Parse("［＃ここから太字］強調文［＃ここで太字終わり］", WithAutoFurigana())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[0].(*Paragraph)
+
+	var on, off bool
+	for _, s := range p.Spans {
+		if tg, ok := s.(*BoldToggle); ok {
+			if tg.On {
+				on = true
+			} else {
+				off = true
+			}
+		}
+	}
+	if !on || !off {
+		t.Errorf("WithAutoFurigana broke the 太字 toggle markers, got %#v", p.Spans)
+	}
+
+	var ruby *Ruby
+	for _, s := range p.Spans {
+		if r, ok := s.(*Ruby); ok {
+			ruby = r
+			break
+		}
+	}
+	if ruby == nil || ruby.Base != "強調文" {
+		t.Errorf("got %#v, want a Ruby span on 強調文 between the toggles", p.Spans)
+	}
+}
+
+func TestParseWarichu(t *testing.T) {
+	doc, err := Parse("本文［＃割り注］割り注の文言［＃割り注終わり］つづき")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[0].(*Paragraph)
+
+	var w *Warichu
+	for _, s := range p.Spans {
+		if v, ok := s.(*Warichu); ok {
+			w = v
+			break
+		}
+	}
+	if w == nil || w.Text != "割り注の文言" {
+		t.Errorf("got %#v, want a Warichu span with text 割り注の文言", p.Spans)
+	}
+}
+
+func TestParseYokogumiBlock(t *testing.T) {
+	doc, err := Parse("［＃ここから横組み］\nE=mc2\n［＃ここで横組み終わり］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[1].(*Paragraph)
+	if !p.Yokogumi {
+		t.Errorf("got Yokogumi=false, want true inside a ここから横組み…ここで横組み終わり run")
+	}
+}
+
+func TestParsePageCenter(t *testing.T) {
+	doc, err := Parse("［＃ページの左右中央］扉")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[0].(*Paragraph)
+	if !p.Center {
+		t.Errorf("got Center=false, want true for ［＃ページの左右中央］")
+	}
+}
+
+func TestParseJizumeBlock(t *testing.T) {
+	doc, err := Parse("［＃ここから２０字詰め］\n字詰め指定された行\n［＃ここで字詰め終わり］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[1].(*Paragraph)
+	if p.Jizume != 20 {
+		t.Errorf("got Jizume=%d, want 20", p.Jizume)
+	}
+}
+
+func TestParseJiageTrimAppliesWhenIndentFits(t *testing.T) {
+	doc, err := Parse("［＃地から２字上げ］［＃５字下げ］本文")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[0].(*Paragraph)
+	if p.Jisage != 3 {
+		t.Errorf("got Jisage=%d, want 5-2=3", p.Jisage)
+	}
+}
+
+func TestParseJiageTrimLeavesIndentUntouchedWhenItDoesNotFit(t *testing.T) {
+	doc, err := Parse("［＃地から８字上げ］［＃２字下げ］本文")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[0].(*Paragraph)
+	if p.Jisage != 2 {
+		t.Errorf("got Jisage=%d, want the original 2 (trim wider than the indent is a no-op)", p.Jisage)
+	}
+}
+
+func TestParseJiageTrimAppliesWhenIndentExactlyFits(t *testing.T) {
+	doc, err := Parse("［＃地から５字上げ］［＃５字下げ］本文")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Blocks[0].(*Paragraph)
+	if p.Jisage != 0 {
+		t.Errorf("got Jisage=%d, want 5-5=0", p.Jisage)
+	}
+}
+
+func TestParsePreformattedBlock(t *testing.T) {
+	doc, err := Parse("［＃ここから罫囲み］\n一行目\n二行目\n［＃ここで罫囲み終わり］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Blocks) != 1 {
+		t.Fatalf("got %d blocks, want 1", len(doc.Blocks))
+	}
+	pre, ok := doc.Blocks[0].(*Preformatted)
+	if !ok {
+		t.Fatalf("got %#v, want *Preformatted", doc.Blocks[0])
+	}
+	want := []string{"一行目", "二行目"}
+	if len(pre.Lines) != len(want) || pre.Lines[0] != want[0] || pre.Lines[1] != want[1] {
+		t.Errorf("got %#v, want %#v", pre.Lines, want)
+	}
+}
+
+func TestParseUnterminatedPreformattedBlockReturnsError(t *testing.T) {
+	_, err := Parse("［＃ここから罫囲み］\n一行目\n二行目")
+	if err == nil {
+		t.Fatal("got nil error, want an error for a missing ［＃ここで罫囲み終わり］")
+	}
+}
+
+func TestRenderPreformattedHTML(t *testing.T) {
+	doc, err := Parse("［＃ここから罫囲み］\n一行目\n二行目\n［＃ここで罫囲み終わり］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(htmlFormatter{})
+	want := "<pre>一行目\n二行目</pre>\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderSSP(t *testing.T) {
+	doc, err := Parse("［＃ここから３字下げ］字下げされた行")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(sspFormatter{})
+	want := "　　　字下げされた行\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+// TestRenderStripsBlockAnnotations guards against a block-scoped 注記
+// marker surviving into rendered output as literal ［＃…］ text: the marker
+// is consumed for its effect (jisage indent, centering, …) but must not
+// also remain in the line's own Text spans.
+func TestRenderStripsBlockAnnotations(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    string
+		notYet string // the bracket text that must not survive into Render
+	}{
+		{"改ページ", "［＃改ページ］本文", "［＃改ページ］"},
+		{"地付き（単独）", "［＃地付き］本文", "［＃地付き］"},
+		{"地付き（ここから）", "［＃ここから地付き］本文\n［＃ここで地付き終わり］", "地付き"},
+		{"見出し", "［＃中見出し］本文", "見出し］"},
+		{"ページの左右中央", "［＃ページの左右中央］本文", "［＃ページの左右中央］"},
+		{"横組み", "［＃ここから横組み］本文\n［＃ここで横組み終わり］", "横組み"},
+		{"字詰め", "［＃ここから２０字詰め］本文\n［＃ここで字詰め終わり］", "字詰め"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, err := Parse(c.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := doc.Render(htmlFormatter{})
+			if strings.Contains(out, c.notYet) {
+				t.Errorf("got %q, want no literal %q in rendered output", out, c.notYet)
+			}
+		})
+	}
+}
+
+func TestRenderHTMLEscapesText(t *testing.T) {
+	doc, err := Parse("A & B <tag>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(htmlFormatter{})
+	want := "A &amp; B &lt;tag&gt;\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderHTMLBold(t *testing.T) {
+	doc, err := Parse("［＃ここから太字］A & B［＃ここで太字終わり］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(htmlFormatter{})
+	want := "<strong>A &amp; B</strong>\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderMarkdownEscapesText(t *testing.T) {
+	doc, err := Parse("A & B <tag>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(markdownFormatter{})
+	want := "A &amp; B &lt;tag&gt;\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderMarkdownRuby(t *testing.T) {
+	doc, err := Parse("｜月《つき》が綺麗ですね")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(markdownFormatter{})
+	want := "<ruby>月<rt>つき</rt></ruby>が綺麗ですね\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderTroffEscapesBackslash(t *testing.T) {
+	doc, err := Parse(`C:\path`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(troffFormatter{})
+	want := `C:\\path` + "\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderGaijiUnicodeResolves(t *testing.T) {
+	doc, err := Parse("※［＃「口＋世」、第3水準1-15-18］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(plainFormatter{}, WithGaijiMode(GaijiUnicode))
+	want := "咻\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderGaijiUnicodeFallsBackToDescriptor(t *testing.T) {
+	doc, err := Parse("※［＃不明な外字］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(plainFormatter{}, WithGaijiMode(GaijiUnicode))
+	want := "〓不明な外字〓\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderGaijiDescriptorMode(t *testing.T) {
+	doc, err := Parse("※［＃不明な外字］")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := doc.Render(plainFormatter{}, WithGaijiMode(GaijiDescriptorMode))
+	want := "〓不明な外字〓\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestParseHeadingLevel(t *testing.T) {
+	cases := []struct {
+		src  string
+		want int
+	}{
+		{"［＃大見出し］本文", 1},
+		{"［＃中見出し］本文", 2},
+		{"［＃小見出し］本文", 3},
+		{"［＃見出し］本文", 1},
+	}
+	for _, c := range cases {
+		doc, err := Parse(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := doc.Blocks[0].(*Paragraph)
+		if p.HeadingLevel != c.want {
+			t.Errorf("Parse(%q): got HeadingLevel=%d, want %d", c.src, p.HeadingLevel, c.want)
+		}
+	}
+}
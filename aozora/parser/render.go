@@ -0,0 +1,149 @@
+package parser
+
+import "github.com/apxxxxxxe/aozoragetter/aozora/gaiji"
+
+// GaijiMode selects how Render handles a ※［＃…］外字注記 whose character
+// Parse couldn't represent directly.
+type GaijiMode int
+
+const (
+	// GaijiPlaceholder renders Formatter.Gaiji's fixed stand-in glyph,
+	// discarding the descriptor. This is Render's default, matching the
+	// behavior formatText always had before gaiji resolution existed.
+	GaijiPlaceholder GaijiMode = iota
+	// GaijiDescriptorMode renders the original descriptor text, via
+	// Formatter.GaijiDescriptor, instead of attempting resolution.
+	GaijiDescriptorMode
+	// GaijiUnicode resolves the descriptor to an actual character via
+	// gaiji.Resolve (optionally consulting GaijiFallback), falling back to
+	// GaijiDescriptorMode's rendering for whatever it can't resolve.
+	GaijiUnicode
+)
+
+// renderSettings holds the settings RenderOption funcs configure.
+type renderSettings struct {
+	gaijiMode     GaijiMode
+	gaijiFallback func(descriptor string) (rune, bool)
+}
+
+// RenderOption configures optional Document.Render behavior.
+type RenderOption func(*renderSettings)
+
+// WithGaijiMode selects how Render handles ※［＃…］外字注記; see GaijiMode.
+func WithGaijiMode(m GaijiMode) RenderOption {
+	return func(s *renderSettings) { s.gaijiMode = m }
+}
+
+// WithGaijiFallback supplies GaijiUnicode's last-resort lookup, consulted
+// for any descriptor gaiji.Resolve's embedded tables don't cover, e.g.
+// backed by a bundled Unihan IDS file or a caller's own corrections. It
+// has no effect except in GaijiUnicode mode.
+func WithGaijiFallback(f func(descriptor string) (rune, bool)) RenderOption {
+	return func(s *renderSettings) { s.gaijiFallback = f }
+}
+
+// Render walks the Document and produces the output dialect described by
+// f. This replaces the old formatText, which interleaved parsing and
+// SSP-specific string building in a single pass.
+func (d *Document) Render(f Formatter, opts ...RenderOption) string {
+	var rs renderSettings
+	for _, opt := range opts {
+		opt(&rs)
+	}
+
+	result := ""
+	for _, b := range d.Blocks {
+		result += renderBlock(b, f, rs)
+	}
+	return result
+}
+
+func renderBlock(b Block, f Formatter, rs renderSettings) string {
+	switch v := b.(type) {
+	case *Paragraph:
+		return renderParagraph(v, f, rs)
+	case *Preformatted:
+		lines := make([]string, len(v.Lines))
+		for i, l := range v.Lines {
+			lines[i] = f.Text(l)
+		}
+		return f.Preformatted(lines) + "\n"
+	default:
+		return ""
+	}
+}
+
+func renderParagraph(p *Paragraph, f Formatter, rs renderSettings) string {
+	text := ""
+	for _, s := range p.Spans {
+		text += renderSpan(s, f, rs)
+	}
+
+	if p.HeadingLevel > 0 {
+		text = f.Heading(p.HeadingLevel, text)
+	}
+	if p.AlignRight {
+		text = f.AlignRight(true) + text + f.AlignRight(false)
+	}
+	if p.Center {
+		text = f.PageCenter(true) + text + f.PageCenter(false)
+	}
+	if p.Yokogumi {
+		text = f.Yokogumi(true) + text + f.Yokogumi(false)
+	}
+	if p.PageBreak {
+		text = f.PageBreak() + text
+	}
+	if p.Jisage > 0 {
+		text = f.Jisage(p.Jisage, text)
+	}
+	if p.Jiage > 0 {
+		text = f.Jiage(p.Jiage, text)
+	}
+	if p.Jizume > 0 {
+		text = f.Jizume(p.Jizume, text)
+	}
+
+	return text + "\n"
+}
+
+func renderSpan(s Span, f Formatter, rs renderSettings) string {
+	switch v := s.(type) {
+	case Text:
+		return f.Text(string(v))
+	case *Ruby:
+		return f.Ruby(f.Text(v.Base), f.Text(v.Reading))
+	case *Gaiji:
+		return renderGaiji(v, f, rs)
+	case *Bold:
+		return f.Bold(true) + f.Text(v.Text) + f.Bold(false)
+	case *Italic:
+		return f.Italic(true) + f.Text(v.Text) + f.Italic(false)
+	case *Bousen:
+		return f.Bousen(true) + f.Text(v.Text) + f.Bousen(false)
+	case *BoldToggle:
+		return f.Bold(v.On)
+	case *ItalicToggle:
+		return f.Italic(v.On)
+	case *BousenToggle:
+		return f.Bousen(v.On)
+	case *Warichu:
+		return f.Warichu(f.Text(v.Text))
+	default:
+		return ""
+	}
+}
+
+func renderGaiji(g *Gaiji, f Formatter, rs renderSettings) string {
+	switch rs.gaijiMode {
+	case GaijiDescriptorMode:
+		return f.GaijiDescriptor(g.Descriptor)
+	case GaijiUnicode:
+		if r, ok := gaiji.Resolve(g.Descriptor, rs.gaijiFallback); ok {
+			return string(r)
+		}
+		return f.GaijiDescriptor(g.Descriptor)
+	default:
+		return f.Gaiji(g.Descriptor)
+	}
+}
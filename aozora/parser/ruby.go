@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/ikawaha/kagome-dict/ipa"
+	"github.com/ikawaha/kagome/v2/tokenizer"
+)
+
+// normalizeRuby rewrites every ｜base《reading》 and bare base《reading》
+// occurrence in src into the canonical 《base,reading》 form rep["ruby"]
+// (in parser.go) looks for. A bare base (no leading ｜) has no explicit left
+// boundary, so the extent of base is recovered by running the IPA
+// tokenizer over the text and walking backwards from 《 while the
+// preceding tokens are entirely 漢字 - the same heuristic the tool has
+// always used.
+func normalizeRuby(src string) (string, error) {
+	t, err := tokenizer.New(ipa.Dict(), tokenizer.OmitBosEos())
+	if err != nil {
+		return "", err
+	}
+	seg := t.Wakati(src)
+
+	out := make([]string, 0, len(seg))
+	inExplicit := false
+	for _, tok := range seg {
+		switch {
+		case tok == "｜":
+			// ｜base《reading》: the ｜ marks where base starts, so the
+			// new 《 goes here; the real 《 (below) becomes the comma.
+			out = append(out, "《")
+			inExplicit = true
+		case tok == "《" && inExplicit:
+			out = append(out, ",")
+			inExplicit = false
+		case tok == "《":
+			// bare base《reading》: base is whatever kanji-only tokens
+			// were just emitted, so pull them back out and re-wrap them.
+			end := len(out)
+			start := end
+			for start > 0 && isKanji(out[start-1]) {
+				start--
+			}
+			base := append([]string{}, out[start:end]...)
+			out = out[:start]
+			out = append(out, "《")
+			out = append(out, base...)
+			out = append(out, ",")
+		default:
+			out = append(out, tok)
+		}
+	}
+
+	return strings.Join(out, ""), nil
+}
+
+// isKanji reports whether s consists entirely of 漢字 (Han script) runes.
+func isKanji(s string) bool {
+	for _, r := range s {
+		if !unicode.In(r, unicode.Han) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,658 @@
+// Package aozoragetter fetches and renders books from Aozora Bunko
+// (青空文庫). Client wraps the author/title index and the aozorahack
+// text mirror; cmd/aozoragetter is a thin CLI built on top of it.
+package aozoragetter
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/encoding/japanese"
+
+	"github.com/apxxxxxxe/aozoragetter/aozora/parser"
+)
+
+const name = "aozoragetter"
+const indexFile = "list_person_all_extended_utf8.csv"
+
+// indexZipURL is a var rather than a const so tests can point it at a
+// file:// fixture and exercise EnsureIndex without network access.
+var indexZipURL = "https://www.aozora.gr.jp/index_pages/list_person_all_extended_utf8.zip"
+
+const defaultMirror = "https://aozorahack.org/aozorabunko_text"
+
+var errIsNotValidBook = errors.New("error: the book is not valid")
+
+// Client searches the aozora index and fetches book text from it. The
+// zero value is not usable - construct one with NewClient so BaseDir is
+// set.
+type Client struct {
+	// BaseDir is where the index file and temporary downloads live.
+	BaseDir string
+	// HTTPClient is used for all requests; http.DefaultClient if nil.
+	HTTPClient *http.Client
+	// Mirror overrides the aozorahack text mirror base URL. It accepts a
+	// file:// root (e.g. a locally rsynced aozorabunko_text tree) as well
+	// as http(s), so a pre-seeded corpus can be used fully offline.
+	Mirror string
+	// Cache controls index refresh behavior; see Cache.
+	Cache Cache
+}
+
+// Cache controls how Client keeps its local copy of the author/title
+// index fresh.
+type Cache struct {
+	// IndexTTL is how long a downloaded index is trusted before
+	// EnsureIndex checks upstream again (via If-Modified-Since). Zero
+	// means never re-check once downloaded.
+	IndexTTL time.Duration
+	// ForceRefreshIndex makes the next EnsureIndex call check upstream
+	// regardless of IndexTTL, e.g. for a --refresh-index flag.
+	ForceRefreshIndex bool
+}
+
+// NewClient returns a Client that keeps its index and downloads under
+// baseDir.
+func NewClient(baseDir string) *Client {
+	return &Client{BaseDir: baseDir}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) mirror() string {
+	if c.Mirror != "" {
+		return c.Mirror
+	}
+	return defaultMirror
+}
+
+// BookInfo identifies one book found via Search.
+type BookInfo struct {
+	Title  string
+	Author string
+	URL    string
+	// CardID is the book's aozora card number (the directory segment in
+	// https://www.aozora.gr.jp/cards/<CardID>/...), used to lay out the
+	// local cache.
+	CardID string
+}
+
+func isFile(filename string) bool {
+	_, err := os.OpenFile(filename, os.O_RDONLY, 0)
+	return !os.IsNotExist(err)
+}
+
+func Unzip(src, dest string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		modTime := f.Modified
+
+		os.MkdirAll(dest, 0755)
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		path := filepath.Join(dest, f.Name)
+		if f.FileInfo().IsDir() {
+			os.MkdirAll(path, f.Mode())
+		} else {
+			f, err := os.OpenFile(
+				path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			_, err = io.Copy(f, rc)
+			if err != nil {
+				return err
+			}
+
+			err = os.Chtimes(path, modTime, modTime)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// progressReader reports cumulative bytes read through onProgress as the
+// wrapped reader is consumed.
+type progressReader struct {
+	r          io.Reader
+	done       int64
+	total      int64
+	onProgress func(done, total int64)
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n > 0 {
+		p.done += int64(n)
+		p.onProgress(p.done, p.total)
+	}
+	return n, err
+}
+
+// fetchOutcome reports what a fetcher did: whether the resource was
+// (re)written to the destination, and its upstream modification time if
+// known.
+type fetchOutcome struct {
+	Modified bool
+	ModTime  time.Time
+}
+
+// fetcher retrieves a URL's content, abstracting over the http and file
+// schemes so Client can treat a locally rsynced mirror the same as the
+// real one. ifModifiedSince, when non-zero, lets the fetcher report
+// Modified: false without writing anything if the resource is no newer.
+type fetcher interface {
+	fetch(ctx context.Context, rawURL string, ifModifiedSince time.Time, w io.Writer, onProgress func(done, total int64)) (fetchOutcome, error)
+}
+
+type httpFetcher struct {
+	client *http.Client
+}
+
+func (f httpFetcher) fetch(ctx context.Context, rawURL string, ifModifiedSince time.Time, w io.Writer, onProgress func(done, total int64)) (fetchOutcome, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fetchOutcome{}, err
+	}
+	if !ifModifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fetchOutcome{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return fetchOutcome{Modified: false}, nil
+	}
+
+	var body io.Reader = resp.Body
+	if onProgress != nil {
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, onProgress: onProgress}
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return fetchOutcome{}, err
+	}
+
+	outcome := fetchOutcome{Modified: true}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		if t, err := http.ParseTime(lm); err == nil {
+			outcome.ModTime = t
+		}
+	}
+	return outcome, nil
+}
+
+// fileFetcher serves a file:// mirror, e.g. a locally rsynced
+// aozorabunko_text tree, so fetching works fully offline.
+type fileFetcher struct{}
+
+func (fileFetcher) fetch(_ context.Context, rawURL string, ifModifiedSince time.Time, w io.Writer, onProgress func(done, total int64)) (fetchOutcome, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fetchOutcome{}, err
+	}
+
+	fi, err := os.Stat(u.Path)
+	if err != nil {
+		return fetchOutcome{}, err
+	}
+	if !ifModifiedSince.IsZero() && !fi.ModTime().After(ifModifiedSince) {
+		return fetchOutcome{Modified: false}, nil
+	}
+
+	fp, err := os.Open(u.Path)
+	if err != nil {
+		return fetchOutcome{}, err
+	}
+	defer fp.Close()
+
+	var r io.Reader = fp
+	if onProgress != nil {
+		r = &progressReader{r: fp, total: fi.Size(), onProgress: onProgress}
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fetchOutcome{}, err
+	}
+
+	return fetchOutcome{Modified: true, ModTime: fi.ModTime()}, nil
+}
+
+func (c *Client) fetcherFor(rawURL string) fetcher {
+	if strings.HasPrefix(rawURL, "file://") {
+		return fileFetcher{}
+	}
+	return httpFetcher{client: c.httpClient()}
+}
+
+// EnsureIndex makes sure the aozora author/title index is present in
+// c.BaseDir and, once Cache.IndexTTL has elapsed (or Cache.ForceRefreshIndex
+// is set), re-checks it upstream via If-Modified-Since rather than
+// re-downloading unconditionally. It reports whether the index was
+// freshly (re)downloaded this call, since Search doesn't need to care but
+// a first-run CLI invocation may want to tell the user to retry once
+// priming is done rather than block on it silently.
+func (c *Client) EnsureIndex(ctx context.Context) (downloaded bool, err error) {
+	indexPath := filepath.Join(c.BaseDir, indexFile)
+
+	exists := isFile(indexPath)
+	stale := false
+	if exists && c.Cache.IndexTTL > 0 {
+		if fi, err := os.Stat(indexPath); err == nil && time.Since(fi.ModTime()) > c.Cache.IndexTTL {
+			stale = true
+		}
+	}
+	if exists && !stale && !c.Cache.ForceRefreshIndex {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(c.BaseDir, 0755); err != nil {
+		return false, err
+	}
+
+	var ifModifiedSince time.Time
+	if exists {
+		if fi, err := os.Stat(indexPath); err == nil {
+			ifModifiedSince = fi.ModTime()
+		}
+	}
+
+	indexZip := filepath.Join(c.BaseDir, "tmp.zip")
+	out, err := os.Create(indexZip)
+	if err != nil {
+		return false, err
+	}
+	outcome, err := c.fetcherFor(indexZipURL).fetch(ctx, indexZipURL, ifModifiedSince, out, nil)
+	out.Close()
+	if err != nil {
+		os.Remove(indexZip)
+		return false, err
+	}
+	defer os.Remove(indexZip)
+
+	if !outcome.Modified {
+		// Upstream agrees nothing changed; just reset the TTL clock.
+		now := time.Now()
+		os.Chtimes(indexPath, now, now)
+		return false, nil
+	}
+
+	if err := Unzip(indexZip, c.BaseDir); err != nil {
+		return false, err
+	}
+	if !outcome.ModTime.IsZero() {
+		os.Chtimes(indexPath, outcome.ModTime, outcome.ModTime)
+	}
+
+	return true, nil
+}
+
+func loadCSV(path string, delim rune) ([][]string, error) {
+	s, err := ioutil.ReadFile(path)
+	if err != nil {
+		return [][]string{}, err
+	}
+
+	r := csv.NewReader(strings.NewReader(string(s)))
+	r.Comma = delim
+
+	result, err := r.ReadAll()
+	if err != nil {
+		return [][]string{}, err
+	}
+
+	return result, nil
+}
+
+func decodeShiftJIS(path string) (string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+
+	decoder := japanese.ShiftJIS.NewDecoder()
+	reader := bufio.NewReader(decoder.Reader(fp))
+	var b strings.Builder
+	for {
+		line, _, err := reader.ReadLine()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	return b.String(), nil
+}
+
+func (c *Client) getBookURL(data []string) (string, error) {
+	rawURL := data[45]
+	preIndex := strings.Index(rawURL, "/card")
+	sufIndex := strings.LastIndex(rawURL, ".zip")
+	if preIndex == -1 || sufIndex == -1 {
+		return "", errIsNotValidBook
+	}
+	fileName := rawURL[strings.LastIndex(rawURL, "/")+1 : sufIndex]
+	return c.mirror() + rawURL[preIndex:sufIndex] + "/" + fileName + ".txt", nil
+}
+
+// cardIDFromURL extracts the aozora card number (the path segment right
+// after "/cards/") from a raw index URL, e.g.
+// ".../cards/000148/files/792_14964.zip" -> "000148".
+func cardIDFromURL(rawURL string) string {
+	const marker = "/cards/"
+	i := strings.Index(rawURL, marker)
+	if i == -1 {
+		return ""
+	}
+	rest := rawURL[i+len(marker):]
+	if j := strings.Index(rest, "/"); j != -1 {
+		return rest[:j]
+	}
+	return rest
+}
+
+func getInfoSummury(bookInfo []string) map[string]string {
+	result := map[string]string{}
+	result["title"] = bookInfo[1]
+	result["author"] = bookInfo[15] + bookInfo[16]
+	return result
+}
+
+func searchBook(query string, indexData [][]string) [][]string {
+	candidates := [][]string{}
+
+	for _, bookInfo := range indexData {
+		infoSummury := getInfoSummury(bookInfo)
+		if strings.Contains(infoSummury["title"], query) || strings.Contains(infoSummury["author"], query) {
+			isUniqueBook := true
+			for _, c := range candidates {
+				s := getInfoSummury(c)
+				if infoSummury["title"] == s["title"] && infoSummury["author"] == s["author"] {
+					// 同作品名、同作者名は同一作品とみなす
+					isUniqueBook = false
+				}
+			}
+			if isUniqueBook {
+				candidates = append(candidates, bookInfo)
+			}
+		}
+	}
+
+	return candidates
+}
+
+// Search looks up books by title/author, narrowing the index down by one
+// query word at a time, and downloads the index into BaseDir first if it
+// isn't already cached there.
+func (c *Client) Search(query ...string) ([]BookInfo, error) {
+	if _, err := c.EnsureIndex(context.Background()); err != nil {
+		return nil, err
+	}
+
+	indexData, err := loadCSV(filepath.Join(c.BaseDir, indexFile), ',')
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := indexData
+	for _, q := range query {
+		// クエリの数だけ繰り返し作品の絞り込み
+		candidates = searchBook(q, candidates)
+	}
+
+	books := make([]BookInfo, 0, len(candidates))
+	for _, row := range candidates {
+		bookURL, err := c.getBookURL(row)
+		if err != nil {
+			continue
+		}
+		s := getInfoSummury(row)
+		books = append(books, BookInfo{Title: s["title"], Author: s["author"], URL: bookURL, CardID: cardIDFromURL(row[45])})
+	}
+
+	return books, nil
+}
+
+// cachePath returns where b's fetched .txt is kept on disk, preserving
+// the aozorahack layout (one directory per card) under baseDir/cache.
+func (c *Client) cachePath(b BookInfo) string {
+	return filepath.Join(c.BaseDir, "cache", b.CardID, path.Base(b.URL))
+}
+
+// fetchToCache downloads b's book text into the local cache, unless it is
+// already there, and returns its path. The upstream modification time
+// (when known) is preserved on the cached file via os.Chtimes.
+func (c *Client) fetchToCache(ctx context.Context, b BookInfo, onProgress func(done, total int64)) (string, error) {
+	cachePath := c.cachePath(b)
+	if isFile(cachePath) {
+		return cachePath, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return "", err
+	}
+
+	out, err := os.Create(cachePath)
+	if err != nil {
+		return "", err
+	}
+	outcome, err := c.fetcherFor(b.URL).fetch(ctx, b.URL, time.Time{}, out, onProgress)
+	out.Close()
+	if err != nil {
+		os.Remove(cachePath)
+		return "", err
+	}
+
+	if !outcome.ModTime.IsZero() {
+		os.Chtimes(cachePath, outcome.ModTime, outcome.ModTime)
+	}
+
+	return cachePath, nil
+}
+
+// Fetch downloads (or reuses a cached copy of) and Shift-JIS-decodes b's
+// book text, returning it as UTF-8. It does not run the注記 parser/
+// formatter - callers that want rendered output should pass the result
+// through Render (or parser.Parse/Document.Render directly), or use
+// FetchAll's Formatter option for the batch case.
+func (c *Client) Fetch(ctx context.Context, b BookInfo) (string, error) {
+	cached, err := c.fetchToCache(ctx, b, nil)
+	if err != nil {
+		return "", err
+	}
+	return decodeShiftJIS(cached)
+}
+
+// Render parses book (already Shift-JIS-decoded Aozora source text, e.g.
+// from Fetch) and renders it through f with the package's default parse
+// options, combining parser.Parse and (*parser.Document).Render into the
+// single call library callers need for the common case. Callers that
+// need non-default parser.Option/parser.RenderOption values should call
+// parser.Parse and Document.Render directly instead.
+func Render(book string, f parser.Formatter) (string, error) {
+	doc, err := parser.Parse(book)
+	if err != nil {
+		return "", err
+	}
+	return doc.Render(f), nil
+}
+
+// FetchStage identifies which step of fetching a book a FetchResult
+// progress event describes.
+type FetchStage int
+
+const (
+	StageDownload FetchStage = iota
+	// StageUnzip is reserved for mirrors that serve zipped book text;
+	// aozorahack serves unzipped (Shift-JIS) .txt files, so FetchAll
+	// never emits it.
+	StageUnzip
+	StageDecode
+	StageFormat
+)
+
+func (s FetchStage) String() string {
+	switch s {
+	case StageDownload:
+		return "download"
+	case StageUnzip:
+		return "unzip"
+	case StageDecode:
+		return "decode"
+	case StageFormat:
+		return "format"
+	default:
+		return "unknown"
+	}
+}
+
+// FetchOptions configures FetchAll.
+type FetchOptions struct {
+	// Concurrency is how many books download at once. Zero means
+	// runtime.NumCPU().
+	Concurrency int
+
+	// Formatter, if set, renders each book through it (with
+	// ParseOptions and RenderOptions) instead of leaving FetchResult.Text
+	// as raw Shift-JIS-decoded UTF-8.
+	Formatter     parser.Formatter
+	ParseOptions  []parser.Option
+	RenderOptions []parser.RenderOption
+}
+
+// FetchResult is one event on the channel FetchAll returns: either a
+// progress update for a book still in flight (Done == false) or its
+// final outcome (Done == true, Text/Err set).
+type FetchResult struct {
+	Book       BookInfo
+	Stage      FetchStage
+	BytesDone  int64
+	BytesTotal int64
+
+	Done bool
+	Text string
+	Err  error
+}
+
+// FetchAll fetches bs concurrently (opts.Concurrency workers, default
+// runtime.NumCPU()), streaming per-book progress and final results
+// through the returned channel, which is closed once every book has been
+// attempted or ctx is done.
+func (c *Client) FetchAll(ctx context.Context, bs []BookInfo, opts FetchOptions) (<-chan FetchResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	jobs := make(chan BookInfo)
+	results := make(chan FetchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				c.fetchOne(ctx, b, opts, results)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, b := range bs {
+			select {
+			case jobs <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results, nil
+}
+
+func (c *Client) fetchOne(ctx context.Context, b BookInfo, opts FetchOptions, results chan<- FetchResult) {
+	emit := func(r FetchResult) {
+		select {
+		case results <- r:
+		case <-ctx.Done():
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		emit(FetchResult{Book: b, Done: true, Err: err})
+		return
+	}
+
+	cached, err := c.fetchToCache(ctx, b, func(done, total int64) {
+		emit(FetchResult{Book: b, Stage: StageDownload, BytesDone: done, BytesTotal: total})
+	})
+	if err != nil {
+		emit(FetchResult{Book: b, Done: true, Err: err})
+		return
+	}
+
+	emit(FetchResult{Book: b, Stage: StageDecode})
+	text, err := decodeShiftJIS(cached)
+	if err != nil {
+		emit(FetchResult{Book: b, Done: true, Err: err})
+		return
+	}
+
+	if opts.Formatter != nil {
+		emit(FetchResult{Book: b, Stage: StageFormat})
+		doc, err := parser.Parse(text, opts.ParseOptions...)
+		if err != nil {
+			emit(FetchResult{Book: b, Done: true, Err: err})
+			return
+		}
+		text = doc.Render(opts.Formatter, opts.RenderOptions...)
+	}
+
+	emit(FetchResult{Book: b, Done: true, Text: text})
+}
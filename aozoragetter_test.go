@@ -0,0 +1,260 @@
+package aozoragetter
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apxxxxxxe/aozoragetter/aozora/parser"
+)
+
+func TestRender(t *testing.T) {
+	f, err := parser.NewFormatter("html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Render("｜月《つき》が綺麗ですね", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<ruby>月<rt>つき</rt></ruby>が綺麗ですね\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func fileURL(path string) string {
+	return "file://" + path
+}
+
+func writeIndexZip(t *testing.T, dir, content string) string {
+	t.Helper()
+	zipPath := filepath.Join(dir, "index.zip")
+	out, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	w := zip.NewWriter(out)
+	f, err := w.Create(indexFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return zipPath
+}
+
+func TestFetchToCacheFileMirror(t *testing.T) {
+	srcDir := t.TempDir()
+	srcPath := filepath.Join(srcDir, "book.txt")
+	if err := os.WriteFile(srcPath, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(t.TempDir())
+	b := BookInfo{CardID: "00001", URL: fileURL(srcPath)}
+
+	got, err := c.fetchToCache(context.Background(), b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != c.cachePath(b) {
+		t.Errorf("got path %q, want %q", got, c.cachePath(b))
+	}
+	data, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("got %q, want %q", data, "hello")
+	}
+}
+
+func TestFetchToCacheSkipsRefetchOnCacheHit(t *testing.T) {
+	srcDir := t.TempDir()
+	srcPath := filepath.Join(srcDir, "book.txt")
+	if err := os.WriteFile(srcPath, []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(t.TempDir())
+	b := BookInfo{CardID: "00001", URL: fileURL(srcPath)}
+
+	if _, err := c.fetchToCache(context.Background(), b, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The source changes after the first fetch; a cache hit must keep
+	// serving the originally cached bytes instead of re-reading it.
+	if err := os.WriteFile(srcPath, []byte("second"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.fetchToCache(context.Background(), b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first" {
+		t.Errorf("got %q after cache hit, want %q (no refetch)", data, "first")
+	}
+}
+
+func TestEnsureIndexDownloadsThenSkipsUntilForcedOrStale(t *testing.T) {
+	fixtureDir := t.TempDir()
+	zipPath := writeIndexZip(t, fixtureDir, "a,b\n")
+	orig := indexZipURL
+	indexZipURL = fileURL(zipPath)
+	defer func() { indexZipURL = orig }()
+
+	baseDir := t.TempDir()
+	c := NewClient(baseDir)
+	indexPath := filepath.Join(baseDir, indexFile)
+
+	downloaded, err := c.EnsureIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !downloaded {
+		t.Fatal("got downloaded=false on first call, want true")
+	}
+	if !isFile(indexPath) {
+		t.Fatal("index file was not written")
+	}
+
+	// No TTL set: a second call must not re-fetch at all.
+	downloaded, err = c.EnsureIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downloaded {
+		t.Error("got downloaded=true on unchanged index with no TTL, want false")
+	}
+
+	// ForceRefreshIndex bypasses the TTL/exists check, but the fetcher
+	// still only reports Modified if the fixture is newer than the
+	// cached index, so bump its mtime forward first.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(zipPath, future, future); err != nil {
+		t.Fatal(err)
+	}
+	c.Cache.ForceRefreshIndex = true
+	downloaded, err = c.EnsureIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !downloaded {
+		t.Error("got downloaded=false with ForceRefreshIndex, want true")
+	}
+	c.Cache.ForceRefreshIndex = false
+
+	// Backdate the index past the TTL so the next call treats it as
+	// stale and checks upstream, even without ForceRefreshIndex.
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(indexPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+	c.Cache.IndexTTL = time.Minute
+	downloaded, err = c.EnsureIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !downloaded {
+		t.Error("got downloaded=false for a stale index, want true")
+	}
+}
+
+func TestEnsureIndexStaleButNotModifiedResetsTTLClock(t *testing.T) {
+	fixtureDir := t.TempDir()
+	zipPath := writeIndexZip(t, fixtureDir, "a,b\n")
+	orig := indexZipURL
+	indexZipURL = fileURL(zipPath)
+	defer func() { indexZipURL = orig }()
+
+	baseDir := t.TempDir()
+	c := NewClient(baseDir)
+	indexPath := filepath.Join(baseDir, indexFile)
+
+	if _, err := c.EnsureIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backdate the cached index past the TTL (so EnsureIndex treats it
+	// as stale and checks upstream) but backdate the fixture even
+	// further, so If-Modified-Since still reports no change.
+	indexTime := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(indexPath, indexTime, indexTime); err != nil {
+		t.Fatal(err)
+	}
+	zipTime := time.Now().Add(-3 * time.Hour)
+	if err := os.Chtimes(zipPath, zipTime, zipTime); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Cache.IndexTTL = time.Hour
+	downloaded, err := c.EnsureIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downloaded {
+		t.Error("got downloaded=true when upstream reports not-modified, want false")
+	}
+	after, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.ModTime().After(before.ModTime()) {
+		t.Error("expected the TTL clock (mtime) to be reset even though content was not modified")
+	}
+}
+
+// FetchAll stops dispatching jobs as soon as ctx is done. Depending on
+// scheduling it may emit a cancellation error for a book that was
+// already picked up, or nothing at all, but it must not hang and any
+// result it does emit must be a terminal error for that book.
+func TestFetchAllCancellation(t *testing.T) {
+	c := NewClient(t.TempDir())
+	bs := []BookInfo{
+		{CardID: "00001", URL: fileURL(filepath.Join(t.TempDir(), "missing.txt"))},
+		{CardID: "00002", URL: fileURL(filepath.Join(t.TempDir(), "missing2.txt"))},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := c.FetchAll(ctx, bs, FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				return
+			}
+			if !r.Done || r.Err == nil {
+				t.Errorf("got in-progress or successful result %+v after ctx cancellation, want a terminal error", r)
+			}
+		case <-deadline:
+			t.Fatal("FetchAll did not close its results channel after ctx cancellation")
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unicode"
+
+	"github.com/mattn/go-isatty"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+
+	aozoragetter "github.com/apxxxxxxe/aozoragetter"
+	"github.com/apxxxxxxe/aozoragetter/aozora/parser"
+)
+
+func main() {
+	sub(os.Args)
+}
+
+func sub(args []string) {
+	/*
+	   終了コード一覧
+	   101: その他事前処理中のエラー
+	   200: インデックスダウンロード成功
+	   201: インデックスダウンロード失敗
+	   301: インデックス読み込み失敗
+	   400: 部分一致する作品群が見つかった
+	   401: 入力に部分一致する作品が見つからなかった
+	   500: 一つの部分一致する作品群を返す(２行目から作品の本文が返る)
+	   501: 作品ファイルの取得に失敗
+	   0: 完全一致する作品が見つかった(２行目から作品の本文が返る)
+	*/
+
+	var execFile string
+	if args[0] == "test" {
+		var err error
+		execFile, err = os.Getwd()
+		if err != nil {
+			fmt.Println(101)
+			return
+		}
+	} else {
+		var err error
+		execFile, err = os.Executable()
+		if err != nil {
+			fmt.Println(101)
+			return
+		}
+	}
+
+	baseDir := filepath.Dir(execFile)
+
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		fmt.Println(101)
+	}
+
+	client := aozoragetter.NewClient(baseDir)
+
+	if len(args) < 2 {
+		fmt.Println(101)
+		return
+	}
+
+	formatName := ""
+	autoRuby := false
+	kanjiListFile := ""
+	mirror := ""
+	refreshIndex := false
+	gaijiMode := ""
+	queryWords := []string{}
+	for i := 1; i < len(args); i++ {
+		if args[i] == "-f" && i+1 < len(args) {
+			formatName = args[i+1]
+			i++
+			continue
+		}
+		if args[i] == "-auto-ruby" {
+			autoRuby = true
+			continue
+		}
+		if args[i] == "-kanji-list" && i+1 < len(args) {
+			kanjiListFile = args[i+1]
+			i++
+			continue
+		}
+		if args[i] == "-mirror" && i+1 < len(args) {
+			mirror = args[i+1]
+			i++
+			continue
+		}
+		if args[i] == "-refresh-index" {
+			refreshIndex = true
+			continue
+		}
+		if args[i] == "-gaiji" && i+1 < len(args) {
+			gaijiMode = args[i+1]
+			i++
+			continue
+		}
+		queryWords = append(queryWords, args[i])
+	}
+
+	if mirror != "" {
+		client.Mirror = mirror
+	}
+	client.Cache.ForceRefreshIndex = refreshIndex
+
+	if downloaded, err := client.EnsureIndex(context.Background()); err != nil {
+		fmt.Println(201)
+		return
+	} else if downloaded {
+		fmt.Println(200)
+		return
+	}
+
+	formatter, err := parser.NewFormatter(formatName)
+	if err != nil {
+		fmt.Println(101)
+		return
+	}
+
+	var parseOpts []parser.Option
+	if autoRuby {
+		parseOpts = append(parseOpts, parser.WithAutoFurigana())
+		if kanjiListFile != "" {
+			kanji, err := loadKanjiList(kanjiListFile)
+			if err != nil {
+				fmt.Println(101)
+				return
+			}
+			parseOpts = append(parseOpts, parser.WithKanjiList(kanji))
+		}
+	}
+
+	renderOpts, err := gaijiRenderOptions(gaijiMode)
+	if err != nil {
+		fmt.Println(101)
+		return
+	}
+
+	books, err := client.Search(queryWords...)
+	if err != nil {
+		fmt.Println(301)
+		return
+	}
+
+	if len(books) > 1 {
+		fmt.Println(400)
+		for _, b := range books {
+			fmt.Println("「" + b.Title + "」" + b.Author)
+		}
+		return
+	} else if len(books) == 0 {
+		fmt.Println(401)
+		return
+	}
+
+	text, err := fetchAndFormat(client, books[0], formatter, parseOpts, renderOpts)
+	if err != nil {
+		fmt.Println(501)
+		return
+	}
+
+	fmt.Println(0)
+	fmt.Println(text)
+}
+
+// gaijiRenderOptions resolves the -gaiji flag value to parser.RenderOptions.
+// An empty name keeps Document.Render's default (GaijiPlaceholder).
+func gaijiRenderOptions(name string) ([]parser.RenderOption, error) {
+	switch name {
+	case "", "placeholder":
+		return nil, nil
+	case "descriptor":
+		return []parser.RenderOption{parser.WithGaijiMode(parser.GaijiDescriptorMode)}, nil
+	case "unicode":
+		return []parser.RenderOption{parser.WithGaijiMode(parser.GaijiUnicode)}, nil
+	default:
+		return nil, fmt.Errorf("unknown -gaiji mode %q", name)
+	}
+}
+
+// fetchAndFormat fetches and renders book. When stdout is a terminal
+// it goes through FetchAll so the download shows an mpb progress bar
+// (drawn to stderr, so stdout still carries only the numeric-code
+// protocol documented above); otherwise it calls Fetch directly.
+func fetchAndFormat(client *aozoragetter.Client, book aozoragetter.BookInfo, f parser.Formatter, parseOpts []parser.Option, renderOpts []parser.RenderOption) (string, error) {
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		raw, err := client.Fetch(context.Background(), book)
+		if err != nil {
+			return "", err
+		}
+		doc, err := parser.Parse(raw, parseOpts...)
+		if err != nil {
+			return "", err
+		}
+		return doc.Render(f, renderOpts...), nil
+	}
+
+	p := mpb.New(mpb.WithOutput(os.Stderr))
+	bar := p.AddBar(100,
+		mpb.PrependDecorators(decor.Name(book.Title)),
+		mpb.AppendDecorators(decor.Percentage()),
+	)
+
+	results, err := client.FetchAll(context.Background(), []aozoragetter.BookInfo{book}, aozoragetter.FetchOptions{
+		Concurrency:   1,
+		Formatter:     f,
+		ParseOptions:  parseOpts,
+		RenderOptions: renderOpts,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var text string
+	var fetchErr error
+	for r := range results {
+		if !r.Done {
+			if r.BytesTotal > 0 {
+				bar.SetCurrent(r.BytesDone * 100 / r.BytesTotal)
+			}
+			continue
+		}
+		text, fetchErr = r.Text, r.Err
+	}
+	bar.SetCurrent(100)
+	p.Wait()
+
+	return text, fetchErr
+}
+
+// loadKanjiList reads a kanji list file for use with parser.WithKanjiList:
+// every 漢字 rune in the file counts, everything else (whitespace,
+// comments, furigana, etc.) is ignored.
+func loadKanjiList(path string) ([]rune, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kanji := []rune{}
+	for _, r := range string(data) {
+		if unicode.In(r, unicode.Han) {
+			kanji = append(kanji, r)
+		}
+	}
+	return kanji, nil
+}